@@ -1,7 +1,7 @@
 /*
- This package provides methods to parse a shell-like command line string into a list of arguments.
+This package provides methods to parse a shell-like command line string into a list of arguments.
 
- Words are split on white spaces, respecting quotes (single and double) and the escape character (backslash)
+Words are split on white spaces, respecting quotes (single and double) and the escape character (backslash)
 */
 package args
 
@@ -36,36 +36,154 @@ var (
 type Scanner struct {
 	in              *bufio.Reader
 	InfieldBrackets bool
+
+	// Filename is reported in positions returned by NextTokenPos; set it via
+	// NewScannerFile.
+	Filename string
+
+	// Mode controls which token classes (comments, idents, numbers, raw
+	// strings) the Scanner recognizes; see ShellMode and GoMode. The zero
+	// value reproduces the Scanner's original behavior.
+	Mode Mode
+
+	// QuoteChars, EscapeChar, SymbolChars and CommentLineChars override the
+	// QUOTE_CHARS, ESCAPE_CHAR, SYMBOL_CHARS defaults and the "#"/"//" line
+	// comment markers respectively. Leave them unset to use the defaults.
+	QuoteChars       string
+	EscapeChar       rune
+	SymbolChars      string
+	CommentLineChars []string
+
+	// ErrorHandler, if set, is called for each scanning error (unterminated
+	// quote, unmatched bracket, trailing backslash) instead of collecting it
+	// in the Scanner's internal ErrorList; see Err.
+	ErrorHandler ErrorHandler
+	errors       ErrorList
+
+	// LineContinuation and Heredocs enable opt-in shell features; see the
+	// GetArgsOption functions of the same name.
+	LineContinuation bool
+	Heredocs         bool
+
+	line       int
+	column     int
+	prevLine   int
+	prevColumn int
 }
 
 // Creates a new Scanner with io.Reader as input source
 func NewScanner(r io.Reader) *Scanner {
-	sc := Scanner{in: bufio.NewReader(r)}
+	sc := Scanner{in: bufio.NewReader(r), line: InitPos.Line, column: InitPos.Column}
 	return &sc
 }
 
 // Creates a new Scanner with a string as input source
 func NewScannerString(s string) *Scanner {
-	sc := Scanner{in: bufio.NewReader(strings.NewReader(s))}
+	sc := Scanner{in: bufio.NewReader(strings.NewReader(s)), line: InitPos.Line, column: InitPos.Column}
 	return &sc
 }
 
+// Creates a new Scanner with io.Reader as input source, reporting name as the
+// Filename in positions returned by NextTokenPos
+func NewScannerFile(name string, r io.Reader) *Scanner {
+	sc := Scanner{in: bufio.NewReader(r), Filename: name, line: InitPos.Line, column: InitPos.Column}
+	return &sc
+}
+
+func (scanner *Scanner) curPos() Pos {
+	return Pos{Filename: scanner.Filename, Line: scanner.line, Column: scanner.column}
+}
+
+// readRune reads the next rune, returning the position it was read from, and
+// advances the scanner's line/column bookkeeping (bumping Line and resetting
+// Column on '\n').
+func (scanner *Scanner) readRune() (c rune, pos Pos, err error) {
+	pos = scanner.curPos()
+
+	c, _, err = scanner.in.ReadRune()
+	if err != nil {
+		return
+	}
+
+	scanner.prevLine, scanner.prevColumn = scanner.line, scanner.column
+	if c == '\n' {
+		scanner.line++
+		scanner.column = 1
+	} else {
+		scanner.column++
+	}
+	return
+}
+
+// unreadRune undoes the last readRune, restoring the line/column it left off at.
+func (scanner *Scanner) unreadRune() error {
+	if err := scanner.in.UnreadRune(); err != nil {
+		return err
+	}
+	scanner.line, scanner.column = scanner.prevLine, scanner.prevColumn
+	return nil
+}
+
+// advance updates line/column bookkeeping for runes consumed in bulk (e.g. a
+// ioutil.ReadAll) without going through readRune, returning the position of
+// the last rune in s (matching readRune's convention of reporting a rune's
+// own position, not the position following it).
+func (scanner *Scanner) advance(s string) Pos {
+	var pos Pos
+	for _, c := range s {
+		pos = scanner.curPos()
+		if c == '\n' {
+			scanner.line++
+			scanner.column = 1
+		} else {
+			scanner.column++
+		}
+	}
+	return pos
+}
+
 // Get the next token from the Scanner, return io.EOF when done
 func (scanner *Scanner) NextToken() (s string, delim int, err error) {
+	s, _, _, delim, err = scanner.nextToken()
+	return
+}
+
+// NextTokenPos behaves like NextToken but additionally reports the start and
+// end positions of the token in the source, so callers building REPLs and
+// shells can point at the exact offending character in diagnostics. Escapes,
+// quotes and bracketed sessions preserve the start position of the
+// containing token, not the inner character.
+func (scanner *Scanner) NextTokenPos() (tok string, start, end Pos, delim int, err error) {
+	return scanner.nextToken()
+}
+
+func (scanner *Scanner) nextToken() (s string, start, end Pos, delim int, err error) {
 	buf := bytes.NewBufferString("")
 	first := true
 	escape := false
 	quote := NO_QUOTE    // invalid character - not a quote
-	brackets := []rune{} // stack of open brackets
+	rawQuote := false    // current quote is a raw string (ScanRawStrings): no escape processing
+	brackets := []rune{} // stack of expected closing brackets
+	openBrackets := []rune{}
+	bracketPos := []Pos{}
+	started := false
+	var escapePos, quotePos Pos
 
 	for {
-		if c, _, e := scanner.in.ReadRune(); e == nil {
+		if c, pos, e := scanner.readRune(); e == nil {
+			if !started && !(first && !escape && unicode.IsSpace(c)) {
+				start = pos
+				started = true
+			}
+
 			//
 			// check escape character
 			//
-			if c == ESCAPE_CHAR && !escape {
+			if !rawQuote && c == scanner.escapeChar() && !escape {
 				escape = true
+				escapePos = pos
 				first = false
+				end = pos
 				continue
 			}
 
@@ -74,7 +192,24 @@ func (scanner *Scanner) NextToken() (s string, delim int, err error) {
 			//
 			if escape {
 				escape = false
+				if scanner.LineContinuation && c == '\n' {
+					//
+					// backslash-newline: drop both, continue on the next line
+					//
+					continue
+				}
+				if scanner.LineContinuation && c == '\r' {
+					//
+					// backslash-CRLF: drop the backslash and the whole line
+					// ending, same as backslash-LF
+					//
+					if peek, e2 := scanner.in.Peek(1); e2 == nil && peek[0] == '\n' {
+						scanner.readRune()
+					}
+					continue
+				}
 				buf.WriteString(string(c))
+				end = pos
 				continue
 			}
 
@@ -91,11 +226,46 @@ func (scanner *Scanner) NextToken() (s string, delim int, err error) {
 
 				first = false
 
-				if strings.ContainsRune(QUOTE_CHARS, c) {
+				if scanner.Mode&ScanLineComments != 0 {
+					if marker, ok := scanner.matchLineComment(c); ok {
+						return scanner.scanLineComment(start, marker)
+					}
+				}
+
+				if scanner.Mode&ScanBlockComments != 0 && c == '/' {
+					if peek, e2 := scanner.in.Peek(1); e2 == nil && peek[0] == '*' {
+						scanner.readRune() // consume '*'
+						return scanner.scanBlockComment(start)
+					}
+				}
+
+				if scanner.Mode&ScanIdents != 0 && isIdentStart(c) {
+					return scanner.scanIdent(start, c)
+				}
+
+				if scanner.Mode&ScanInts != 0 && unicode.IsDigit(c) {
+					return scanner.scanNumber(start, c)
+				}
+
+				if scanner.Heredocs && c == '<' {
+					if dash, ok := scanner.matchHeredocStart(); ok {
+						tag, quotedTag, e2 := scanner.readHeredocTag()
+						if e2 != nil {
+							s, err = tag, e2
+							return
+						}
+						return scanner.scanHeredocBody(start, tag, quotedTag, dash)
+					}
+				}
+
+				if strings.ContainsRune(scanner.quoteChars(), c) {
 					//
 					// start quoted token
 					//
 					quote = c
+					quotePos = pos
+					rawQuote = scanner.Mode&ScanRawStrings != 0 && c == '`'
+					end = pos
 					continue
 				}
 
@@ -105,18 +275,27 @@ func (scanner *Scanner) NextToken() (s string, delim int, err error) {
 					//
 					delim = int(c)
 					brackets = append(brackets, b)
+					openBrackets = append(openBrackets, c)
+					bracketPos = append(bracketPos, pos)
 					buf.WriteString(string(c))
+					end = pos
 					continue
 				}
 
-				if strings.ContainsRune(SYMBOL_CHARS, c) {
+				if strings.ContainsRune(scanner.symbolChars(), c) {
 					//
 					// if it's a symbol, return  all the remaining characters
 					//
 					buf.WriteString(string(c))
-					_, err = io.Copy(buf, scanner.in)
+					end = pos
+					rest, e2 := ioutil.ReadAll(scanner.in)
+					buf.Write(rest)
+					if len(rest) > 0 {
+						end = scanner.advance(string(rest))
+					}
 					s = buf.String()
-					return // (token, delim, err)
+					err = e2
+					return // (token, start, end, delim, err)
 				}
 			}
 
@@ -127,7 +306,7 @@ func (scanner *Scanner) NextToken() (s string, delim int, err error) {
 				if unicode.IsSpace(c) && quote == NO_QUOTE {
 					s = buf.String()
 					delim = int(c)
-					return // (token, delim, nil)
+					return // (token, start, end, delim, nil)
 				}
 
 				//
@@ -135,9 +314,11 @@ func (scanner *Scanner) NextToken() (s string, delim int, err error) {
 				//
 				if c == quote {
 					quote = NO_QUOTE
+					rawQuote = false
+					end = pos
 					s = buf.String()
 					delim = int(c)
-					return // (token, delim, nil)
+					return // (token, start, end, delim, nil)
 				}
 
 				if scanner.InfieldBrackets {
@@ -146,6 +327,8 @@ func (scanner *Scanner) NextToken() (s string, delim int, err error) {
 						// start a bracketed session
 						//
 						brackets = append(brackets, b)
+						openBrackets = append(openBrackets, c)
+						bracketPos = append(bracketPos, pos)
 					}
 				}
 
@@ -153,47 +336,63 @@ func (scanner *Scanner) NextToken() (s string, delim int, err error) {
 				// append to buffer
 				//
 				buf.WriteString(string(c))
+				end = pos
 			} else {
 				//
 				// append to buffer
 				//
 				buf.WriteString(string(c))
+				end = pos
 
 				last := len(brackets) - 1
 
 				if quote == NO_QUOTE {
 					if c == brackets[last] {
 						brackets = brackets[:last] // pop
+						openBrackets = openBrackets[:last]
+						bracketPos = bracketPos[:last]
 
 						if len(brackets) == 0 {
 							s = buf.String()
-							return // (token, delim, nil)
+							return // (token, start, end, delim, nil)
 						}
-					} else if strings.ContainsRune(QUOTE_CHARS, c) {
+					} else if strings.ContainsRune(scanner.quoteChars(), c) {
 						//
 						// start quoted token
 						//
 						quote = c
+						quotePos = pos
+						rawQuote = scanner.Mode&ScanRawStrings != 0 && c == '`'
 					} else if b, ok := BRACKETS[c]; ok {
 						brackets = append(brackets, b)
+						openBrackets = append(openBrackets, c)
+						bracketPos = append(bracketPos, pos)
 					}
 				} else if c == quote {
 					quote = NO_QUOTE
+					rawQuote = false
 				}
 			}
 		} else {
 			if e == io.EOF {
+				if escape {
+					scanner.error(escapePos, "trailing backslash")
+				}
+				if quote != NO_QUOTE {
+					scanner.error(quotePos, fmt.Sprintf("unterminated %s quote opened at %s", quoteName(quote), quotePos))
+				}
+				if len(brackets) > 0 {
+					scanner.error(bracketPos[0], fmt.Sprintf("unmatched '%c' opened at %s", openBrackets[0], bracketPos[0]))
+				}
 				if buf.Len() > 0 {
 					s = buf.String()
-					return // (token, 0, nil)
+					return // (token, start, end, 0, nil)
 				}
 			}
 			err = e
-			return // ("", 0, io.EOF)
+			return // ("", start, end, 0, io.EOF)
 		}
 	}
-
-	return
 }
 
 // Return all tokens as an array of strings
@@ -202,6 +401,21 @@ func (scanner *Scanner) GetTokens() (tokens []string, err error) {
 	return
 }
 
+// GetTokensPos behaves like GetTokens but returns each token together with
+// the source positions it spans.
+func (scanner *Scanner) GetTokensPos() (tokens []Token, err error) {
+	for {
+		text, start, end, delim, e := scanner.NextTokenPos()
+		if e != nil {
+			if e != io.EOF {
+				err = e
+			}
+			return
+		}
+		tokens = append(tokens, Token{Text: text, Delim: delim, Start: start, End: end})
+	}
+}
+
 func (scanner *Scanner) GetTokensN(n int) ([]string, string, error) {
 	return scanner.getTokens(n)
 }
@@ -219,7 +433,7 @@ func (scanner *Scanner) getTokens(max int) ([]string, string, error) {
 	for i := 0; max <= 0 || i < max; i++ {
 		if options {
 			for {
-				c, _, err := scanner.in.ReadRune()
+				c, _, err := scanner.readRune()
 				if err == io.EOF {
 					return tokens, "", nil
 				}
@@ -228,13 +442,14 @@ func (scanner *Scanner) getTokens(max int) ([]string, string, error) {
 				}
 
 				if c == OPTION_CHAR {
-					scanner.in.UnreadRune()
+					scanner.unreadRune()
 					break
 				}
 
 				if !unicode.IsSpace(c) {
-					scanner.in.UnreadRune()
+					scanner.unreadRune()
 					rest, err := ioutil.ReadAll(scanner.in)
+					scanner.advance(string(rest))
 					return tokens, string(rest), err
 				}
 
@@ -251,6 +466,7 @@ func (scanner *Scanner) getTokens(max int) ([]string, string, error) {
 	}
 
 	rest, err := ioutil.ReadAll(scanner.in)
+	scanner.advance(string(rest))
 	if err == io.EOF {
 		err = nil
 	}
@@ -267,6 +483,23 @@ func InfieldBrackets() GetArgsOption {
 	}
 }
 
+// LineContinuation enables backslash-newline continuation: an unescaped \
+// immediately followed by a newline is dropped entirely and scanning
+// continues on the next line as if the newline weren't there.
+func LineContinuation() GetArgsOption {
+	return func(s *Scanner) {
+		s.LineContinuation = true
+	}
+}
+
+// Heredocs enables <<TAG and <<-TAG heredoc bodies, returned as a single
+// token with delimiter HeredocDelim.
+func Heredocs() GetArgsOption {
+	return func(s *Scanner) {
+		s.Heredocs = true
+	}
+}
+
 func getScanner(line string, options ...GetArgsOption) *Scanner {
 	scanner := NewScannerString(line)
 
@@ -284,6 +517,15 @@ func GetArgs(line string, options ...GetArgsOption) (args []string) {
 	return
 }
 
+// GetArgsErr behaves like GetArgs but also reports any unterminated quotes,
+// unmatched brackets or trailing backslashes encountered while scanning,
+// instead of silently returning a truncated slice.
+func GetArgsErr(line string, options ...GetArgsOption) (args []string, err error) {
+	scanner := getScanner(line, options...)
+	args, _, _ = scanner.GetTokensN(0)
+	return args, scanner.Err()
+}
+
 // Parse the input line into an array of max n arguments
 func GetArgsN(line string, n int, options ...GetArgsOption) []string {
 	scanner := getScanner(line, options...)
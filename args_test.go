@@ -2,6 +2,7 @@ package args
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -41,6 +42,233 @@ func TestScannerInfieldBrackets(test *testing.T) {
 	}
 }
 
+func TestScannerPos(test *testing.T) {
+	scanner := NewScannerFile("test.txt", strings.NewReader("one two\nthree"))
+
+	for {
+		token, start, end, delim, err := scanner.NextTokenPos()
+		if err != nil {
+			test.Log(err)
+			break
+		}
+
+		test.Logf("%q %q %s-%s", delim, token, start, end)
+	}
+}
+
+func TestGetTokensPos(test *testing.T) {
+	scanner := NewScannerString(TEST_STRING)
+
+	tokens, err := scanner.GetTokensPos()
+	if err != nil {
+		test.Fatal(err)
+	}
+
+	for _, token := range tokens {
+		test.Logf("%q %s-%s", token.Text, token.Start, token.End)
+	}
+}
+
+func TestScannerShellMode(test *testing.T) {
+	scanner := NewScannerString("one two # a comment\nthree")
+	scanner.Mode = ShellMode
+
+	for {
+		token, delim, err := scanner.NextToken()
+		if err != nil {
+			test.Log(err)
+			break
+		}
+
+		test.Logf("%q %q", delim, token)
+	}
+}
+
+func TestScannerGoMode(test *testing.T) {
+	scanner := NewScannerString("func add(a, b int) int { // sums two ints\n\treturn a + 3.14 /* block */\n}")
+	scanner.Mode = GoMode
+
+	for {
+		token, delim, err := scanner.NextToken()
+		if err != nil {
+			test.Log(err)
+			break
+		}
+
+		test.Logf("%q %q", delim, token)
+	}
+}
+
+func TestScannerRawStrings(test *testing.T) {
+	scanner := NewScannerString("one `raw\\nstring` two")
+	scanner.Mode = ScanRawStrings
+
+	for {
+		token, delim, err := scanner.NextToken()
+		if err != nil {
+			test.Log(err)
+			break
+		}
+
+		test.Logf("%q %q", delim, token)
+	}
+}
+
+func TestTokenStream(test *testing.T) {
+	stream := NewScannerString("key = value, next").Stream()
+
+	key, err := stream.Expect(' ')
+	if err != nil {
+		test.Fatal(err)
+	}
+	test.Logf("key %q", key.Text)
+
+	peeked, err := stream.Peek()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if again, _ := stream.Peek(); again.Text != peeked.Text {
+		test.Fatalf("Peek not idempotent: %q != %q", again.Text, peeked.Text)
+	}
+
+	eq, err := stream.Next()
+	if err != nil {
+		test.Fatal(err)
+	}
+	test.Logf("eq %q", eq.Text)
+
+	if _, err := stream.Expect('\x00'); err == nil {
+		test.Fatal("expected an error for a mismatched delimiter")
+	} else {
+		test.Log(err)
+	}
+
+	value, err := stream.Next()
+	if err != nil {
+		test.Fatal(err)
+	}
+	stream.Unread(value)
+
+	again, err := stream.Next()
+	if err != nil || again.Text != value.Text {
+		test.Fatalf("Unread did not replay the token: %q %v", again.Text, err)
+	}
+}
+
+func TestGetArgsErr(test *testing.T) {
+	for _, line := range []string{
+		`one "unterminated`,
+		`one {unmatched [brackets`,
+		`one\`,
+	} {
+		args, err := GetArgsErr(line)
+		if err == nil {
+			test.Fatalf("%q: expected an error", line)
+		}
+		test.Logf("%q => %q: %s", line, args, err)
+	}
+
+	if _, err := GetArgsErr(`one two "three"`); err != nil {
+		test.Fatalf("unexpected error for well-formed input: %s", err)
+	}
+}
+
+func TestScannerErrorHandler(test *testing.T) {
+	var got []string
+	scanner := NewScannerString(`"unterminated`)
+	scanner.ErrorHandler = func(pos Pos, msg string) {
+		got = append(got, fmt.Sprintf("%s: %s", pos, msg))
+	}
+
+	if _, _, err := scanner.NextToken(); err != nil {
+		test.Fatalf("expected a nil err with the truncated token, got %v", err)
+	}
+
+	if len(got) != 1 {
+		test.Fatalf("expected 1 reported error, got %v", got)
+	}
+	test.Log(got[0])
+
+	if err := scanner.Err(); err != nil {
+		test.Fatalf("errors handled by ErrorHandler should not also appear in Err(): %s", err)
+	}
+}
+
+func TestLineContinuation(test *testing.T) {
+	args := GetArgs("one two\\\nthree", LineContinuation())
+	test.Logf("%q", args)
+
+	if len(args) != 2 || args[0] != "one" || args[1] != "twothree" {
+		test.Fatalf("unexpected result: %q", args)
+	}
+}
+
+func TestLineContinuationCRLF(test *testing.T) {
+	args := GetArgs("one two\\\r\nthree", LineContinuation())
+	test.Logf("%q", args)
+
+	if len(args) != 2 || args[0] != "one" || args[1] != "twothree" {
+		test.Fatalf("unexpected result: %q", args)
+	}
+}
+
+func TestHeredoc(test *testing.T) {
+	scanner := NewScannerString("cat <<-EOF\n\tone\n\ttwo\n\tEOF\nnext")
+	scanner.Heredocs = true
+
+	for {
+		token, delim, err := scanner.NextToken()
+		if err != nil {
+			test.Log(err)
+			break
+		}
+
+		test.Logf("%d %q", delim, token)
+	}
+}
+
+func TestHeredocCRLF(test *testing.T) {
+	scanner := NewScannerString("cat <<EOF\r\nline one\r\nEOF\r\nnext")
+	scanner.Heredocs = true
+
+	scanner.NextToken() // "cat"
+	body, delim, err := scanner.NextToken()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if delim != HeredocDelim {
+		test.Fatalf("expected HeredocDelim, got %d", delim)
+	}
+	if body != "line one\r\n" {
+		test.Fatalf("unexpected body: %q", body)
+	}
+	if scanner.Err() != nil {
+		test.Fatalf("CRLF-terminated tag line should match, got: %v", scanner.Err())
+	}
+}
+
+func TestHeredocQuotedTag(test *testing.T) {
+	scanner := NewScannerString("cat <<'EOF'\nraw \\n text\nEOF\n")
+	scanner.Heredocs = true
+
+	token, delim, err := scanner.NextToken()
+	if err != nil {
+		test.Fatal(err)
+	}
+	test.Logf("%q", token)
+
+	body, delim, err := scanner.NextToken()
+	if err != nil {
+		test.Fatal(err)
+	}
+	if delim != HeredocDelim {
+		test.Fatalf("expected HeredocDelim, got %d", delim)
+	}
+	if body != "raw \\n text\n" {
+		test.Fatalf("quoted heredoc tag should disable escape processing, got %q", body)
+	}
+}
+
 func TestGetArgs(test *testing.T) {
 
 	test.Logf("%q", GetArgs(TEST_STRING))
@@ -0,0 +1,97 @@
+package args
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ErrorHandler is called for each error encountered while scanning, with the
+// position the error originated at and a message describing what went
+// wrong. If no ErrorHandler is set, errors are appended to an internal
+// ErrorList retrievable via Scanner.Err.
+type ErrorHandler func(pos Pos, msg string)
+
+// Error is a single scanning error together with the position it occurred at.
+type Error struct {
+	Pos Pos
+	Msg string
+}
+
+func (err *Error) Error() string {
+	return fmt.Sprintf("%s: %s", err.Pos, err.Msg)
+}
+
+// ErrorList is a list of *Error that itself implements error.
+type ErrorList []*Error
+
+// Add appends an error to the list.
+func (list *ErrorList) Add(pos Pos, msg string) {
+	*list = append(*list, &Error{Pos: pos, Msg: msg})
+}
+
+func (list ErrorList) Len() int      { return len(list) }
+func (list ErrorList) Swap(i, j int) { list[i], list[j] = list[j], list[i] }
+func (list ErrorList) Less(i, j int) bool {
+	a, b := list[i].Pos, list[j].Pos
+	if a.Filename != b.Filename {
+		return a.Filename < b.Filename
+	}
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	return a.Column < b.Column
+}
+
+// Sort sorts the list by position.
+func (list ErrorList) Sort() {
+	sort.Sort(list)
+}
+
+// Err returns the list as an error, or nil if the list is empty.
+func (list ErrorList) Err() error {
+	if len(list) == 0 {
+		return nil
+	}
+	return list
+}
+
+func (list ErrorList) Error() string {
+	switch len(list) {
+	case 0:
+		return "no errors"
+	case 1:
+		return list[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", list[0], len(list)-1)
+}
+
+// error reports msg at pos via scanner.ErrorHandler if set, otherwise it
+// records the error on scanner.errors for later retrieval via Scanner.Err.
+func (scanner *Scanner) error(pos Pos, msg string) {
+	if scanner.ErrorHandler != nil {
+		scanner.ErrorHandler(pos, msg)
+		return
+	}
+	scanner.errors.Add(pos, msg)
+}
+
+// Err returns the errors accumulated while scanning (unterminated quotes,
+// unmatched brackets, trailing backslashes), or nil if there were none. It
+// is always nil when an ErrorHandler is set, since errors are reported there
+// instead of being collected.
+func (scanner *Scanner) Err() error {
+	return scanner.errors.Err()
+}
+
+func quoteName(q rune) string {
+	switch q {
+	case '"':
+		return "double"
+	case '\'':
+		return "single"
+	case '`':
+		return "backtick"
+	default:
+		return fmt.Sprintf("%q", q)
+	}
+}
@@ -0,0 +1,136 @@
+package args
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// HeredocDelim is the delimiter NextToken/NextTokenPos report for a heredoc
+// body, which is only possible when the Heredocs() option is set.
+const HeredocDelim = -2
+
+// matchHeredocStart checks whether the scanner is positioned right after a
+// leading '<' that begins a heredoc ("<<TAG" or "<<-TAG"), consuming the
+// rest of the marker ("<" and an optional "-") if so.
+func (scanner *Scanner) matchHeredocStart() (dash, ok bool) {
+	peek, err := scanner.in.Peek(1)
+	if err != nil || peek[0] != '<' {
+		return false, false
+	}
+	scanner.readRune() // consume the second '<'
+
+	if peek, err := scanner.in.Peek(1); err == nil && peek[0] == '-' {
+		scanner.readRune() // consume '-'
+		dash = true
+	}
+	return dash, true
+}
+
+// readHeredocTag reads the TAG following "<<"/"<<-": either a quoted word,
+// which disables escape processing in the body, or a plain word terminated
+// by whitespace or EOF.
+func (scanner *Scanner) readHeredocTag() (tag string, quoted bool, err error) {
+	c, _, err := scanner.readRune()
+	if err != nil {
+		return "", false, err
+	}
+
+	if strings.ContainsRune(scanner.quoteChars(), c) {
+		quote := c
+		buf := bytes.NewBufferString("")
+		for {
+			c, _, e := scanner.readRune()
+			if e != nil {
+				return buf.String(), true, nil
+			}
+			if c == quote {
+				return buf.String(), true, nil
+			}
+			buf.WriteString(string(c))
+		}
+	}
+
+	buf := bytes.NewBufferString(string(c))
+	for {
+		c, _, e := scanner.readRune()
+		if e != nil {
+			return buf.String(), false, nil
+		}
+		if unicode.IsSpace(c) {
+			//
+			// leave the whitespace for scanHeredocBody to skip over along
+			// with the rest of the "<<TAG" line
+			//
+			scanner.unreadRune()
+			return buf.String(), false, nil
+		}
+		buf.WriteString(string(c))
+	}
+}
+
+// scanHeredocBody reads lines until one consisting solely of tag (preceded
+// only by tabs, when dash is set), returning the accumulated body as a
+// single token with delimiter HeredocDelim. Unless quoted, a backslash
+// escapes the character that follows it, same as everywhere else in the
+// Scanner.
+func (scanner *Scanner) scanHeredocBody(start Pos, tag string, quoted, dash bool) (string, Pos, Pos, int, error) {
+	end := start
+
+	//
+	// skip to the end of the "<<TAG" line itself; anything trailing the tag
+	// there is not part of the heredoc body
+	//
+	for {
+		c, pos, e := scanner.readRune()
+		if e != nil {
+			scanner.error(start, fmt.Sprintf("unterminated heredoc <<%s opened at %s", tag, start))
+			return "", start, end, HeredocDelim, nil
+		}
+		end = pos
+		if c == '\n' {
+			break
+		}
+	}
+
+	body := bytes.NewBufferString("")
+	line := bytes.NewBufferString("")
+
+	for {
+		c, pos, e := scanner.readRune()
+		if e != nil {
+			scanner.error(start, fmt.Sprintf("unterminated heredoc <<%s opened at %s", tag, start))
+			body.Write(line.Bytes())
+			return body.String(), start, end, HeredocDelim, nil
+		}
+		end = pos
+
+		if c == '\n' {
+			trimmed := strings.TrimSuffix(line.String(), "\r")
+			if dash {
+				trimmed = strings.TrimLeft(trimmed, "\t")
+			}
+			if trimmed == tag {
+				return body.String(), start, end, HeredocDelim, nil
+			}
+			body.Write(line.Bytes())
+			body.WriteByte('\n')
+			line.Reset()
+			continue
+		}
+
+		if !quoted && c == scanner.escapeChar() {
+			c2, pos2, e2 := scanner.readRune()
+			if e2 != nil {
+				line.WriteRune(c)
+				continue
+			}
+			end = pos2
+			line.WriteRune(c2)
+			continue
+		}
+
+		line.WriteRune(c)
+	}
+}
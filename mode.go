@@ -0,0 +1,198 @@
+package args
+
+import (
+	"bytes"
+	"unicode"
+)
+
+// Mode is a bitmask of Scanner behavior flags, in the spirit of
+// text/scanner.Mode. The zero value reproduces the Scanner's original
+// behavior: no comment, ident, number or raw-string recognition.
+type Mode uint
+
+const (
+	ScanIdents Mode = 1 << iota
+	ScanInts
+	ScanFloats
+	ScanRawStrings
+	ScanComments
+	SkipComments
+	ScanLineComments
+	ScanBlockComments
+)
+
+// ShellMode treats a "#" (or "//") at the start of a token as a line comment
+// that is dropped, rather than returned as a SYMBOL_CHARS token as the zero
+// Mode does.
+const ShellMode = SkipComments | ScanLineComments
+
+// GoMode scans Go-like source: identifiers, ints, floats, backtick raw
+// strings, and both line and block comments, the latter returned as tokens
+// (with delimiter CommentDelim) rather than dropped.
+const GoMode = ScanIdents | ScanInts | ScanFloats | ScanRawStrings | ScanComments | ScanLineComments | ScanBlockComments
+
+// CommentDelim is the delimiter NextToken/NextTokenPos report for a comment
+// token, which is only possible when Mode has ScanComments set.
+const CommentDelim = -1
+
+func (scanner *Scanner) quoteChars() string {
+	if scanner.QuoteChars != "" {
+		return scanner.QuoteChars
+	}
+	return QUOTE_CHARS
+}
+
+func (scanner *Scanner) escapeChar() rune {
+	if scanner.EscapeChar != 0 {
+		return scanner.EscapeChar
+	}
+	return ESCAPE_CHAR
+}
+
+func (scanner *Scanner) symbolChars() string {
+	if scanner.SymbolChars != "" {
+		return scanner.SymbolChars
+	}
+	return SYMBOL_CHARS
+}
+
+func (scanner *Scanner) commentLineChars() []string {
+	if scanner.CommentLineChars != nil {
+		return scanner.CommentLineChars
+	}
+	return []string{"#", "//"}
+}
+
+// matchLineComment reports whether c begins one of the scanner's line
+// comment markers, consuming the rest of the marker (beyond c) if so.
+func (scanner *Scanner) matchLineComment(c rune) (marker string, ok bool) {
+	for _, m := range scanner.commentLineChars() {
+		if m == "" || rune(m[0]) != c {
+			continue
+		}
+		if len(m) == 1 {
+			return m, true
+		}
+		if peek, err := scanner.in.Peek(len(m) - 1); err == nil && string(peek) == m[1:] {
+			for range m[1:] {
+				scanner.readRune()
+			}
+			return m, true
+		}
+	}
+	return "", false
+}
+
+func isIdentStart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c)
+}
+
+func isIdentPart(c rune) bool {
+	return c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// finishComment returns the scanned comment as a token when ScanComments is
+// set; otherwise it drops the comment and scans the next real token.
+func (scanner *Scanner) finishComment(text string, start, end Pos) (string, Pos, Pos, int, error) {
+	if scanner.Mode&ScanComments != 0 {
+		return text, start, end, CommentDelim, nil
+	}
+	return scanner.nextToken()
+}
+
+func (scanner *Scanner) scanLineComment(start Pos, marker string) (string, Pos, Pos, int, error) {
+	buf := bytes.NewBufferString(marker)
+	end := start
+
+	for {
+		c, pos, err := scanner.readRune()
+		if err != nil {
+			break
+		}
+		if c == '\n' {
+			scanner.unreadRune()
+			break
+		}
+		end = pos
+		buf.WriteString(string(c))
+	}
+
+	return scanner.finishComment(buf.String(), start, end)
+}
+
+func (scanner *Scanner) scanBlockComment(start Pos) (string, Pos, Pos, int, error) {
+	buf := bytes.NewBufferString("/*")
+	end := start
+
+	for {
+		c, pos, err := scanner.readRune()
+		if err != nil {
+			break
+		}
+		end = pos
+		buf.WriteString(string(c))
+
+		if c == '*' {
+			if peek, e := scanner.in.Peek(1); e == nil && peek[0] == '/' {
+				c2, pos2, _ := scanner.readRune()
+				buf.WriteString(string(c2))
+				end = pos2
+				break
+			}
+		}
+	}
+
+	return scanner.finishComment(buf.String(), start, end)
+}
+
+func (scanner *Scanner) scanIdent(start Pos, first rune) (string, Pos, Pos, int, error) {
+	buf := bytes.NewBufferString(string(first))
+	end := start
+	delim := 0
+
+	for {
+		c, pos, err := scanner.readRune()
+		if err != nil {
+			break
+		}
+		if !isIdentPart(c) {
+			scanner.unreadRune()
+			delim = int(c)
+			break
+		}
+		end = pos
+		buf.WriteString(string(c))
+	}
+
+	return buf.String(), start, end, delim, nil
+}
+
+func (scanner *Scanner) scanNumber(start Pos, first rune) (string, Pos, Pos, int, error) {
+	buf := bytes.NewBufferString(string(first))
+	end := start
+	delim := 0
+	sawDot := false
+
+	for {
+		c, pos, err := scanner.readRune()
+		if err != nil {
+			break
+		}
+		if unicode.IsDigit(c) {
+			end = pos
+			buf.WriteString(string(c))
+			continue
+		}
+		if c == '.' && !sawDot && scanner.Mode&ScanFloats != 0 {
+			sawDot = true
+			end = pos
+			buf.WriteString(string(c))
+			continue
+		}
+		scanner.unreadRune()
+		delim = int(c)
+		break
+	}
+
+	return buf.String(), start, end, delim, nil
+}
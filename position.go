@@ -0,0 +1,33 @@
+package args
+
+import "fmt"
+
+// Pos describes a location in scanned source: an optional filename together
+// with a 1-based line and column, mirroring the position types used by
+// go/token and HashiCorp's hil.
+type Pos struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+// InitPos is the position of the first rune of a fresh input.
+var InitPos = Pos{Line: 1, Column: 1}
+
+// String renders the position as "file:line:col", or just "line:col" when
+// Filename is empty.
+func (pos Pos) String() string {
+	if pos.Filename == "" {
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", pos.Filename, pos.Line, pos.Column)
+}
+
+// Token is a single scanned token together with the source range it spans
+// and the delimiter that ended it (see Scanner.NextTokenPos).
+type Token struct {
+	Text  string
+	Delim int
+	Start Pos
+	End   Pos
+}
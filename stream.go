@@ -0,0 +1,115 @@
+package args
+
+import "fmt"
+
+// TokenStream is a peekable LL(1) view over a Scanner's tokens. It lets
+// parser authors building on args implement small recursive-descent
+// grammars (e.g. for key=value lists, sub-commands, or bracketed
+// expressions) without manually juggling bufio.Reader.UnreadRune and
+// without re-reading runes that already crossed quote/bracket state
+// transitions.
+type TokenStream struct {
+	scanner *Scanner
+	buf     *Token // one token of lookahead, nil when empty
+}
+
+// Stream returns a TokenStream reading tokens from the scanner.
+func (scanner *Scanner) Stream() *TokenStream {
+	return &TokenStream{scanner: scanner}
+}
+
+// Peek returns the next token without consuming it.
+func (ts *TokenStream) Peek() (Token, error) {
+	if ts.buf == nil {
+		tok, err := ts.read()
+		if err != nil {
+			return Token{}, err
+		}
+		ts.buf = &tok
+	}
+	return *ts.buf, nil
+}
+
+// Next returns and consumes the next token.
+func (ts *TokenStream) Next() (Token, error) {
+	if ts.buf != nil {
+		tok := *ts.buf
+		ts.buf = nil
+		return tok, nil
+	}
+	return ts.read()
+}
+
+// Unread pushes tok back onto the stream so the next Peek/Next returns it
+// again. Only one token of lookahead is supported; Unread panics if the
+// buffer is already full.
+func (ts *TokenStream) Unread(tok Token) {
+	if ts.buf != nil {
+		panic("args: TokenStream.Unread called with a token already buffered")
+	}
+	ts.buf = &tok
+}
+
+func (ts *TokenStream) read() (Token, error) {
+	text, start, end, delim, err := ts.scanner.NextTokenPos()
+	if err != nil {
+		return Token{}, err
+	}
+	return Token{Text: text, Delim: delim, Start: start, End: end}, nil
+}
+
+// Expect consumes the next token if its delimiter matches delim, otherwise
+// it returns an *UnexpectedTokenError positioned at that token and leaves it
+// in the stream.
+func (ts *TokenStream) Expect(delim int) (Token, error) {
+	return ts.ExpectAny(delim)
+}
+
+// ExpectAny consumes the next token if its delimiter matches one of delims,
+// otherwise it returns an *UnexpectedTokenError positioned at that token and
+// leaves it in the stream.
+func (ts *TokenStream) ExpectAny(delims ...int) (Token, error) {
+	tok, err := ts.Peek()
+	if err != nil {
+		return Token{}, err
+	}
+
+	for _, delim := range delims {
+		if tok.Delim == delim {
+			return ts.Next()
+		}
+	}
+
+	return Token{}, &UnexpectedTokenError{Pos: tok.Start, Got: tok.Delim, Expected: delims}
+}
+
+// UnexpectedTokenError reports that the next token in a TokenStream didn't
+// match what a parser expected.
+type UnexpectedTokenError struct {
+	Pos      Pos
+	Got      int
+	Expected []int
+}
+
+func (err *UnexpectedTokenError) Error() string {
+	return fmt.Sprintf("%s: unexpected %s, expected %s", err.Pos, delimString(err.Got), expectedDelims(err.Expected))
+}
+
+func delimString(delim int) string {
+	switch delim {
+	case 0:
+		return "EOF"
+	case CommentDelim:
+		return "comment"
+	default:
+		return fmt.Sprintf("%q", rune(delim))
+	}
+}
+
+func expectedDelims(delims []int) string {
+	buf := make([]string, len(delims))
+	for i, delim := range delims {
+		buf[i] = delimString(delim)
+	}
+	return fmt.Sprint(buf)
+}